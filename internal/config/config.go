@@ -0,0 +1,171 @@
+// Package config loads and validates rss-rag-ingest's YAML configuration.
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Main is the root of config.yml.
+type Main struct {
+	DBFile     string         `yaml:"db_file"`
+	ContentDir string         `yaml:"content_dir"`
+	OpenWebUI  *OpenWebUI     `yaml:"open-webui"`
+	Fetch      *FetchConfig   `yaml:"fetch"`
+	Dedup      *DedupConfig   `yaml:"dedup"`
+	RSS        []*RSSEndpoint `yaml:"rss"`
+
+	// Workers sets the default number of items processed concurrently
+	// per feed. Any feed that doesn't set its own `workers` inherits
+	// this value; falls back to DefaultWorkers when unset.
+	Workers int `yaml:"workers"`
+}
+
+// DedupConfig controls which signals are used to recognize an item
+// that's already been ingested.
+type DedupConfig struct {
+	By []string `yaml:"by"`
+}
+
+// ByContent reports whether content-hash dedup is enabled. It defaults
+// to off, since it requires extracting and hashing every item's body.
+func (d *DedupConfig) ByContent() bool {
+	return d != nil && contains(d.By, "content")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchConfig controls the shared HTTP client used to retrieve feeds and
+// the content they link to.
+type FetchConfig struct {
+	UserAgent        string  `yaml:"user_agent"`
+	RateLimitPerHost float64 `yaml:"rate_limit_per_host"`
+	MaxRetries       int     `yaml:"max_retries"`
+}
+
+// OpenWebUI holds credentials for the Open-WebUI instance that receives
+// ingested knowledge files.
+type OpenWebUI struct {
+	APIEndpoint string `yaml:"api_endpoint"`
+	APIToken    string `yaml:"api_token"`
+
+	// UploadRateLimit caps how many knowledge uploads per second may be
+	// in flight toward Open-WebUI, across every feed and worker.
+	// Defaults to DefaultUploadRateLimit when unset.
+	UploadRateLimit float64 `yaml:"upload_rate_limit"`
+}
+
+// RSSEndpoint describes a single feed to poll and how to handle its items.
+type RSSEndpoint struct {
+	Id              string `yaml:"id"`
+	Name            string `yaml:"name"`
+	Url             string `yaml:"url"`
+	DataInLink      bool   `yaml:"data_in_link"`
+	AuthorOverride  string `yaml:"author_override"`
+	HtmlToMarkdown  bool   `yaml:"html_to_markdown"`
+	KnowledgeBaseId string `yaml:"owui_knowledge_base"`
+
+	// PollInterval controls how often the daemon re-polls this feed. It is
+	// ignored in one-shot mode. Defaults to DefaultPollInterval when unset.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// Cron, when set, takes precedence over PollInterval and schedules
+	// polls using a standard 5-field cron expression instead of a fixed
+	// interval.
+	Cron string `yaml:"cron"`
+	// Concurrency bounds how many polls of this feed may be in flight at
+	// once. Defaults to DefaultConcurrency when unset.
+	Concurrency int `yaml:"concurrency"`
+	// Workers bounds how many items from a single poll of this feed are
+	// processed concurrently. Defaults to Main.Workers, or
+	// DefaultWorkers when that's also unset.
+	Workers int `yaml:"workers"`
+
+	// Sinks lists where ingested items get pushed. When empty and
+	// KnowledgeBaseId is set, a single owui sink is assumed for
+	// backwards compatibility with configs predating fanout support.
+	Sinks []*SinkConfig `yaml:"sinks"`
+
+	// RespectRobotsTxt enables robots.txt enforcement when following
+	// this feed's item links.
+	RespectRobotsTxt bool `yaml:"respect_robots_txt"`
+
+	// Transform lists the ordered steps (see the transform package's
+	// Step constants) used to turn an item into its rendered body.
+	// Unset means no content-transforming steps run before the
+	// template; see transform.DefaultTemplate.
+	Transform []string `yaml:"transform"`
+	// Template is the Go text/template source used to render an item's
+	// final body. Unset falls back to transform.DefaultTemplate.
+	Template string `yaml:"template"`
+}
+
+// SinkConfig configures one destination an item gets pushed to. Type
+// selects which fields apply: "owui", "micropub", or "filesystem".
+type SinkConfig struct {
+	Type string `yaml:"type"`
+
+	// owui
+	KnowledgeBaseId string `yaml:"knowledge_base_id"`
+
+	// micropub
+	MicropubEndpoint string `yaml:"micropub_endpoint"`
+	MicropubToken    string `yaml:"micropub_token"`
+}
+
+// DefaultPollInterval is used for any feed that doesn't set poll_interval
+// or cron in daemon mode.
+const DefaultPollInterval = 15 * time.Minute
+
+// DefaultConcurrency is used for any feed that doesn't set concurrency.
+const DefaultConcurrency = 1
+
+// DefaultWorkers is used for any feed that doesn't set workers, and
+// whose Main config doesn't set a global default either.
+const DefaultWorkers = 4
+
+// Load reads and parses the YAML config file at path.
+func Load(path string) (*Main, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer fh.Close()
+
+	yamlBytes, err := io.ReadAll(fh)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	config := &Main{}
+	if err := yaml.Unmarshal(yamlBytes, config); err != nil {
+		return nil, fmt.Errorf("unmarshaling %s: %w", path, err)
+	}
+
+	for _, rss := range config.RSS {
+		if rss.PollInterval <= 0 {
+			rss.PollInterval = DefaultPollInterval
+		}
+		if rss.Concurrency <= 0 {
+			rss.Concurrency = DefaultConcurrency
+		}
+		if rss.Workers <= 0 {
+			rss.Workers = config.Workers
+		}
+		if rss.Workers <= 0 {
+			rss.Workers = DefaultWorkers
+		}
+	}
+
+	return config, nil
+}
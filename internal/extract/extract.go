@@ -0,0 +1,49 @@
+// Package extract pulls the readable main content out of an HTML
+// document, stripping navigation, footers, scripts, and other
+// boilerplate before the page is handed off to rendering or a sink.
+package extract
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/go-shiori/go-readability"
+)
+
+// Article is the main content pulled out of a page, stripped of
+// boilerplate.
+type Article struct {
+	// Content is the cleaned-up HTML of the article body.
+	Content string
+	// Text is the plain-text rendering of Content, suitable for hashing
+	// or further conversion.
+	Text string
+}
+
+// Readable runs a density-based main-content extraction over html,
+// using pageURL (if known) to resolve relative links and images.
+// pageURL may be empty.
+func Readable(html string, pageURL string) (Article, error) {
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		parsed = nil
+	}
+
+	article, err := readability.FromReader(strings.NewReader(html), parsed)
+	if err != nil {
+		return Article{}, fmt.Errorf("extracting readable content: %w", err)
+	}
+
+	return Article{Content: article.Content, Text: article.TextContent}, nil
+}
+
+var whitespaceRun = regexp.MustCompile(`\s+`)
+
+// NormalizeText collapses whitespace and lowercases s so that two
+// renderings of essentially the same article hash identically
+// regardless of incidental formatting differences.
+func NormalizeText(s string) string {
+	return strings.ToLower(whitespaceRun.ReplaceAllString(strings.TrimSpace(s), " "))
+}
@@ -0,0 +1,324 @@
+// Package fetch retrieves feeds and the content they link to, with
+// conditional-GET caching, retries, per-host rate limiting, and optional
+// robots.txt enforcement.
+package fetch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+	"golang.org/x/time/rate"
+
+	"github.com/litui/rss-rag-ingest/internal/config"
+	"github.com/litui/rss-rag-ingest/internal/store"
+)
+
+// AcceptedContentTypes are the MIME types we know how to turn into
+// knowledge files.
+var AcceptedContentTypes = []string{
+	"text/html",
+	"text/plain",
+	"text/markdown",
+	"application/pdf",
+}
+
+// ContentTypeExtensions maps an accepted content type to the file
+// extension used when caching it on disk.
+var ContentTypeExtensions = map[string]string{
+	"text/html":       ".html",
+	"text/plain":      ".txt",
+	"text/markdown":   ".md",
+	"application/pdf": ".pdf",
+}
+
+const (
+	defaultUserAgent        = "Mozilla/5.0"
+	defaultMaxRetries       = 3
+	defaultRateLimitPerHost = 1.0
+	defaultRetryBackoff     = 500 * time.Millisecond
+)
+
+// Client fetches URLs through a shared *http.Client, caching responses
+// in sqlite so subsequent fetches can be conditional GETs.
+type Client struct {
+	hc         *http.Client
+	db         *sql.DB
+	userAgent  string
+	maxRetries int
+	rateLimit  float64
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+	robots   map[string]*robotstxt.RobotsData
+}
+
+// NewClient builds a Client that caches responses in db and identifies
+// itself with cfg.UserAgent (or a sensible default).
+func NewClient(db *sql.DB, cfg *config.FetchConfig) *Client {
+	c := &Client{
+		hc: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: 10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		db:         db,
+		userAgent:  defaultUserAgent,
+		maxRetries: defaultMaxRetries,
+		rateLimit:  defaultRateLimitPerHost,
+		limiters:   make(map[string]*rate.Limiter),
+		robots:     make(map[string]*robotstxt.RobotsData),
+	}
+
+	if cfg != nil {
+		if cfg.UserAgent != "" {
+			c.userAgent = cfg.UserAgent
+		}
+		if cfg.MaxRetries > 0 {
+			c.maxRetries = cfg.MaxRetries
+		}
+		if cfg.RateLimitPerHost > 0 {
+			c.rateLimit = cfg.RateLimitPerHost
+		}
+	}
+
+	return c
+}
+
+// FetchContent retrieves rawURL and returns its body along with the
+// detected content type, provided it is one of AcceptedContentTypes. If
+// respectRobots is set, a disallowed URL is rejected without a request
+// ever being made.
+func (c *Client) FetchContent(ctx context.Context, rawURL string, respectRobots bool) (*[]byte, string, error) {
+	body, contentType, err := c.do(ctx, rawURL, respectRobots)
+	if err != nil {
+		return nil, "", err
+	}
+
+	foundAccepted := false
+	matched := "text/html"
+	for _, t := range AcceptedContentTypes {
+		if strings.Contains(contentType, t) {
+			foundAccepted = true
+			matched = t
+			break
+		}
+	}
+	if !foundAccepted {
+		return nil, "", errors.New("unreadable response")
+	}
+
+	return &body, matched, nil
+}
+
+// FetchFeed retrieves the raw feed document at feedURL, reusing a cached
+// copy via conditional GET when the server reports it's unchanged.
+func (c *Client) FetchFeed(ctx context.Context, feedURL string) ([]byte, error) {
+	body, _, err := c.do(ctx, feedURL, false)
+	return body, err
+}
+
+// do performs the cached, rate-limited, retrying GET at the heart of
+// both FetchContent and FetchFeed. It returns the raw (unfiltered)
+// Content-Type header alongside the body.
+func (c *Client) do(ctx context.Context, rawURL string, respectRobots bool) ([]byte, string, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if respectRobots {
+		allowed, err := c.allowedByRobots(ctx, target)
+		if err != nil {
+			slog.Error("Error checking robots.txt", "host", target.Host, "err", err)
+		} else if !allowed {
+			return nil, "", fmt.Errorf("disallowed by robots.txt: %s", rawURL)
+		}
+	}
+
+	cached, err := store.GetCacheEntry(ctx, c.db, rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading cache: %w", err)
+	}
+
+	limiter := c.limiterFor(target.Host)
+
+	var lastErr error
+	backoff := defaultRetryBackoff
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, "", err
+		}
+
+		body, contentType, notModified, retryAfter, err := c.attempt(ctx, rawURL, cached, backoff)
+		if err != nil {
+			lastErr = err
+			select {
+			case <-ctx.Done():
+				return nil, "", ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			continue
+		}
+
+		if retryAfter > 0 {
+			lastErr = fmt.Errorf("retryable response from %s", rawURL)
+			select {
+			case <-ctx.Done():
+				return nil, "", ctx.Err()
+			case <-time.After(retryAfter):
+			}
+			backoff *= 2
+			continue
+		}
+
+		if notModified {
+			return cached.Body, cached.ContentType, nil
+		}
+
+		return body, contentType, nil
+	}
+
+	return nil, "", fmt.Errorf("giving up on %s after %d attempts: %w", rawURL, c.maxRetries+1, lastErr)
+}
+
+// attempt performs a single HTTP round trip. A non-zero retryAfter means
+// the caller should back off and retry; it is mutually exclusive with a
+// non-nil error and with notModified. fallbackBackoff is the wait used
+// for a 429/5xx response that doesn't send its own Retry-After, so the
+// caller's growing backoff still applies on that path instead of a flat
+// delay.
+func (c *Client) attempt(ctx context.Context, rawURL string, cached *store.CacheEntry, fallbackBackoff time.Duration) (body []byte, contentType string, notModified bool, retryAfter time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", false, 0, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, "", false, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		if cached == nil {
+			return nil, "", false, 0, errors.New("304 response with no cached entry")
+		}
+		return nil, "", true, 0, nil
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, "", false, retryAfterDuration(resp.Header, fallbackBackoff), nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", false, 0, fmt.Errorf("non-200 response code: %d", resp.StatusCode)
+	}
+
+	contentType = resp.Header.Get("Content-Type")
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", false, 0, err
+	}
+
+	entry := &store.CacheEntry{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  contentType,
+		Body:         body,
+	}
+	if err := store.PutCacheEntry(ctx, c.db, rawURL, entry); err != nil {
+		slog.Error("Error caching response", "url", rawURL, "err", err)
+	}
+
+	return body, contentType, false, 0, nil
+}
+
+func (c *Client) limiterFor(host string) *rate.Limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.limiters[host]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(c.rateLimit), 1)
+		c.limiters[host] = l
+	}
+	return l
+}
+
+func (c *Client) allowedByRobots(ctx context.Context, target *url.URL) (bool, error) {
+	c.mu.Lock()
+	robots, ok := c.robots[target.Host]
+	c.mu.Unlock()
+
+	if !ok {
+		robotsURL := fmt.Sprintf("%s://%s/robots.txt", target.Scheme, target.Host)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+		if err != nil {
+			return true, err
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+
+		resp, err := c.hc.Do(req)
+		if err != nil {
+			// Can't reach robots.txt; fail open rather than blocking all
+			// fetches to the host.
+			return true, nil
+		}
+		defer resp.Body.Close()
+
+		parsed, err := robotstxt.FromResponse(resp)
+		if err != nil {
+			return true, nil
+		}
+
+		c.mu.Lock()
+		c.robots[target.Host] = parsed
+		c.mu.Unlock()
+		robots = parsed
+	}
+
+	return robots.TestAgent(target.Path, c.userAgent), nil
+}
+
+// retryAfterDuration reads the Retry-After header (seconds or an HTTP
+// date), falling back to fallback when it's absent, unparseable, or
+// doesn't name a positive wait.
+func retryAfterDuration(h http.Header, fallback time.Duration) time.Duration {
+	ra := h.Get("Retry-After")
+	if ra == "" {
+		return fallback
+	}
+	if secs, err := strconv.Atoi(ra); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if when, err := http.ParseTime(ra); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,78 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterDurationSeconds(t *testing.T) {
+	h := http.Header{}
+	h.Set("Retry-After", "30")
+
+	got := retryAfterDuration(h, defaultRetryBackoff)
+	if got != 30*time.Second {
+		t.Errorf("retryAfterDuration() = %v, want 30s", got)
+	}
+}
+
+func TestRetryAfterDurationHTTPDate(t *testing.T) {
+	when := time.Now().Add(2 * time.Minute)
+	h := http.Header{}
+	h.Set("Retry-After", when.UTC().Format(http.TimeFormat))
+
+	got := retryAfterDuration(h, defaultRetryBackoff)
+	if got <= 0 || got > 2*time.Minute {
+		t.Errorf("retryAfterDuration() = %v, want ~2m", got)
+	}
+}
+
+func TestRetryAfterDurationPastHTTPDate(t *testing.T) {
+	when := time.Now().Add(-2 * time.Minute)
+	h := http.Header{}
+	h.Set("Retry-After", when.UTC().Format(http.TimeFormat))
+
+	got := retryAfterDuration(h, defaultRetryBackoff)
+	if got != defaultRetryBackoff {
+		t.Errorf("retryAfterDuration() = %v, want fallback %v for a past date", got, defaultRetryBackoff)
+	}
+}
+
+func TestRetryAfterDurationMissingOrUnparseable(t *testing.T) {
+	cases := []http.Header{
+		{},
+		{"Retry-After": []string{"not-a-valid-value"}},
+	}
+
+	for _, h := range cases {
+		if got := retryAfterDuration(h, defaultRetryBackoff); got != defaultRetryBackoff {
+			t.Errorf("retryAfterDuration(%v) = %v, want fallback %v", h, got, defaultRetryBackoff)
+		}
+	}
+}
+
+// TestAttemptHonorsGrowingFallbackBackoff guards against a server error
+// with no Retry-After header falling back to a flat defaultRetryBackoff
+// instead of the caller's own growing backoff.
+func TestAttemptHonorsGrowingFallbackBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := &Client{hc: http.DefaultClient, userAgent: "test"}
+	fallback := 4 * time.Second
+
+	_, _, notModified, retryAfter, err := c.attempt(context.Background(), srv.URL, nil, fallback)
+	if err != nil {
+		t.Fatalf("attempt() error = %v", err)
+	}
+	if notModified {
+		t.Fatalf("attempt() notModified = true, want false")
+	}
+	if retryAfter != fallback {
+		t.Errorf("attempt() retryAfter = %v, want the caller's backoff %v", retryAfter, fallback)
+	}
+}
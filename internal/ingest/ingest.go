@@ -0,0 +1,403 @@
+// Package ingest drives a single pass over a feed: fetching items, turning
+// them into knowledge files, and recording what's already been seen.
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/time/rate"
+
+	"github.com/litui/rss-rag-ingest/internal/config"
+	"github.com/litui/rss-rag-ingest/internal/extract"
+	"github.com/litui/rss-rag-ingest/internal/fetch"
+	"github.com/litui/rss-rag-ingest/internal/owui"
+	"github.com/litui/rss-rag-ingest/internal/render"
+	"github.com/litui/rss-rag-ingest/internal/sink"
+	"github.com/litui/rss-rag-ingest/internal/store"
+	"github.com/litui/rss-rag-ingest/internal/transform"
+)
+
+// Pipeline holds the shared dependencies needed to ingest any feed.
+type Pipeline struct {
+	Config *config.Main
+	DB     *sql.DB
+	Parser *gofeed.Parser
+	Fetch  *fetch.Client
+
+	// OWUILimiter throttles every owui sink built by this Pipeline,
+	// since the upload rate limit is per Open-WebUI instance rather
+	// than per feed.
+	OWUILimiter *rate.Limiter
+
+	transformMu sync.Mutex
+	transforms  map[string]*transform.Pipeline
+
+	sinksMu sync.Mutex
+	sinks   map[string][]sink.Sink
+
+	// contentClaims tracks content hashes currently being processed by
+	// some goroutine in this pipeline, so that two items racing on the
+	// same content (e.g. a repost picked up by two feeds at once) don't
+	// both slip past the FindByContentHash check and get pushed twice
+	// before either has recorded its hash. See claimContentHash.
+	contentClaims sync.Map
+}
+
+// claimContentHash reserves contentHash for the caller's exclusive use
+// until the returned release func is called. ok is false if some other
+// goroutine already holds the claim, meaning contentHash is currently
+// being ingested elsewhere and this item should be treated as a
+// duplicate without being pushed to any sink. An empty contentHash
+// (content dedup disabled) is never contended.
+func (p *Pipeline) claimContentHash(contentHash string) (release func(), ok bool) {
+	if contentHash == "" {
+		return func() {}, true
+	}
+	if _, loaded := p.contentClaims.LoadOrStore(contentHash, struct{}{}); loaded {
+		return nil, false
+	}
+	return func() { p.contentClaims.Delete(contentHash) }, true
+}
+
+// NewPipeline builds a Pipeline ready to process feeds from cfg.
+func NewPipeline(cfg *config.Main, db *sql.DB) *Pipeline {
+	return &Pipeline{
+		Config:      cfg,
+		DB:          db,
+		Parser:      gofeed.NewParser(),
+		Fetch:       fetch.NewClient(db, cfg.Fetch),
+		OWUILimiter: owui.NewLimiter(cfg.OpenWebUI),
+		transforms:  make(map[string]*transform.Pipeline),
+		sinks:       make(map[string][]sink.Sink),
+	}
+}
+
+// transformFor returns the transform pipeline for rssEndpoint, building
+// and caching it the first time it's needed.
+func (p *Pipeline) transformFor(rssEndpoint *config.RSSEndpoint) (*transform.Pipeline, error) {
+	p.transformMu.Lock()
+	defer p.transformMu.Unlock()
+
+	if tp, ok := p.transforms[rssEndpoint.Id]; ok {
+		return tp, nil
+	}
+
+	tp, err := transform.NewPipeline(rssEndpoint.Transform, rssEndpoint.Template)
+	if err != nil {
+		return nil, err
+	}
+	p.transforms[rssEndpoint.Id] = tp
+	return tp, nil
+}
+
+// sinksFor returns the sinks for rssEndpoint, building and caching them
+// the first time they're needed, so a sink like Micropub's that owns an
+// *http.Client isn't rebuilt (and its connections discarded) per item.
+func (p *Pipeline) sinksFor(rssEndpoint *config.RSSEndpoint) []sink.Sink {
+	p.sinksMu.Lock()
+	defer p.sinksMu.Unlock()
+
+	if sinks, ok := p.sinks[rssEndpoint.Id]; ok {
+		return sinks
+	}
+
+	sinks := buildSinks(p.Config.OpenWebUI, p.OWUILimiter, rssEndpoint)
+	p.sinks[rssEndpoint.Id] = sinks
+	return sinks
+}
+
+// ProcessEndpoint fetches rssEndpoint's feed once and ingests any items
+// that haven't been seen before, processing up to rssEndpoint.Workers of
+// them concurrently. ctx being canceled aborts any items still in
+// flight without losing ones that already committed.
+func (p *Pipeline) ProcessEndpoint(ctx context.Context, rssEndpoint *config.RSSEndpoint) error {
+	feedBytes, err := p.Fetch.FetchFeed(ctx, rssEndpoint.Url)
+	if err != nil {
+		return fmt.Errorf("fetching feed: %w", err)
+	}
+
+	feed, err := p.Parser.Parse(bytes.NewReader(feedBytes))
+	if err != nil {
+		return fmt.Errorf("parsing feed: %w", err)
+	}
+
+	workers := rssEndpoint.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	items := make(chan *gofeed.Item)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rssItem := range items {
+				p.processItem(ctx, feed, rssEndpoint, rssItem)
+			}
+		}()
+	}
+
+feedItems:
+	for _, rssItem := range feed.Items {
+		select {
+		case <-ctx.Done():
+			break feedItems
+		case items <- rssItem:
+		}
+	}
+	close(items)
+	wg.Wait()
+
+	return nil
+}
+
+func (p *Pipeline) processItem(ctx context.Context, feed *gofeed.Feed, rssEndpoint *config.RSSEndpoint, rssItem *gofeed.Item) {
+	start := time.Now()
+	fileName := ""
+	var content *[]byte
+	contentType := ""
+	// data_in_link feeds are essentially linkblogs: the entry itself is
+	// just a pointer to the external resource, so it reads as a
+	// bookmark rather than a self-contained note.
+	hasBody := !rssEndpoint.DataInLink
+
+	hash := store.FindItem(ctx, p.DB, rssEndpoint.Id, rssItem.GUID)
+	if hash != "" {
+		// Already in DB
+		return
+	}
+
+	hash = store.GenItemHash(rssEndpoint.Id, rssItem.GUID)
+	contentHash := ""
+	itemLog := slog.With("feed", rssEndpoint.Id, "guid", rssItem.GUID, "hash", hash)
+
+	if rssEndpoint.DataInLink {
+		// Travel to link and retrieve body
+
+		fetched, fetchedContentType, err := p.Fetch.FetchContent(ctx, rssItem.Link, rssEndpoint.RespectRobotsTxt)
+		contentType = fetchedContentType
+		if err != nil {
+			itemLog.Error("Error fetching content", "err", err)
+			return
+		}
+		content = fetched
+
+		if contentType == "text/html" {
+			article, err := extract.Readable(string(*content), rssItem.Link)
+			if err != nil {
+				// Boilerplate-stripping is best-effort; fall back to the
+				// raw page rather than dropping the item.
+				itemLog.Warn("Error extracting readable content", "err", err)
+			} else {
+				cleaned := []byte(article.Content)
+				content = &cleaned
+
+				if p.Config.Dedup.ByContent() {
+					contentHash = store.GenContentHash(extract.NormalizeText(article.Text))
+
+					// Claim before checking the DB, so a rival that records
+					// this hash and releases its claim in between can't be
+					// missed by the check below.
+					release, claimed := p.claimContentHash(contentHash)
+					if !claimed {
+						// Another goroutine in this process is ingesting
+						// the same content right now; let it win.
+						itemLog.Info("Skipping item: same content already being ingested", "content_hash", contentHash)
+						return
+					}
+					defer release()
+
+					if store.FindByContentHash(ctx, p.DB, contentHash) {
+						// Same article, different feed or GUID
+						return
+					}
+				}
+			}
+		}
+
+		// Optionally convert html to markdown (might make it more legible to the embedder)
+		if contentType == "text/html" && rssEndpoint.HtmlToMarkdown {
+			markdown, err := render.HTMLToMarkdown(string(*content))
+			if err != nil {
+				itemLog.Error("Error converting HTML to markdown", "err", err)
+				return
+			}
+
+			newContent := []byte(markdown)
+			content = &newContent
+			contentType = "text/markdown"
+		}
+
+		// Cache data on filesystem
+		filePath := fmt.Sprintf("%s/%s %s %s%s", p.Config.ContentDir, rssEndpoint.Name, rssItem.PublishedParsed.Format("2006-01-02 15:04:05"), hash[0:6], fetch.ContentTypeExtensions[contentType])
+		fh, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			itemLog.Error("Error opening file", "err", err)
+			return
+		}
+		defer fh.Close()
+		fileName = fh.Name()
+		if _, err := fh.Write(*content); err != nil {
+			itemLog.Error("Error writing file", "err", err)
+			return
+		}
+	} else {
+		// Create a wee markdown file when not following links
+
+		if render.SkipLinkOnly(rssItem) {
+			return
+		}
+
+		tp, err := p.transformFor(rssEndpoint)
+		if err != nil {
+			itemLog.Error("Error building transform pipeline", "err", err)
+			return
+		}
+
+		templateAuthors := []string{rssEndpoint.AuthorOverride}
+		if rssEndpoint.AuthorOverride == "" {
+			templateAuthors = nil
+			for _, a := range rssItem.Authors {
+				templateAuthors = append(templateAuthors, a.Name)
+			}
+		}
+
+		data := transform.TemplateData{
+			Feed: transform.FeedData{Title: rssEndpoint.Name, Description: feed.Description},
+			Item: transform.ItemData{
+				Title:      rssItem.Title,
+				Link:       rssItem.Link,
+				Authors:    templateAuthors,
+				Categories: rssItem.Categories,
+				Content:    rssItem.Description,
+			},
+			Meta: transform.MetaData{Hash: hash},
+		}
+		if rssItem.PublishedParsed != nil {
+			data.Item.Published = *rssItem.PublishedParsed
+		}
+
+		body, err := tp.Render(data)
+		if err != nil {
+			itemLog.Error("Error rendering transform template", "err", err)
+			return
+		}
+
+		filePath := fmt.Sprintf("%s/%s %s %s.md", p.Config.ContentDir, rssEndpoint.Name, rssItem.PublishedParsed.Format("2006-01-02 15:04:05"), hash[0:6])
+
+		fh, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			itemLog.Error("Error opening file", "err", err)
+			return
+		}
+		defer fh.Close()
+		fileName = fh.Name()
+
+		if _, err := fh.WriteString(body); err != nil {
+			itemLog.Error("Error writing file", "err", err)
+			return
+		}
+
+		bodyBytes := []byte(body)
+		content = &bodyBytes
+	}
+
+	if fileName == "" || content == nil {
+		return
+	}
+
+	if contentType == "" {
+		contentType = "text/markdown"
+	}
+
+	authors := make([]string, 0, len(rssItem.Authors))
+	for _, a := range rssItem.Authors {
+		authors = append(authors, a.Name)
+	}
+
+	sinkItem := sink.Item{
+		FileName:    fileName,
+		Content:     *content,
+		ContentType: contentType,
+		Title:       rssItem.Title,
+		Link:        rssItem.Link,
+		Authors:     authors,
+		Categories:  rssItem.Categories,
+		HasBody:     hasBody,
+	}
+	if rssItem.PublishedParsed != nil {
+		sinkItem.Published = *rssItem.PublishedParsed
+	}
+
+	if !p.pushToSinks(ctx, itemLog, rssEndpoint, sinkItem) {
+		return
+	}
+
+	// Use ctx's values but not its cancellation: once a sink push has
+	// succeeded, the item is committed even if a shutdown signal landed
+	// in the meantime, so it isn't re-ingested on the next run.
+	recordCtx := context.WithoutCancel(ctx)
+	if err := store.RecordItem(recordCtx, p.DB, rssEndpoint.Id, rssItem.GUID, hash, contentHash); err != nil {
+		if errors.Is(err, store.ErrDuplicateContent) {
+			// Backstop for the table's unique constraints: should be rare
+			// given claimContentHash, but can still happen when another
+			// process sharing the database recorded the same content
+			// hash first, or an overlapping poll of this feed recorded
+			// the same GUID.
+			itemLog.Info("Skipping item: content hash already recorded", "content_hash", contentHash)
+			return
+		}
+		itemLog.Error("Error recording item in DB", "err", err)
+		return
+	}
+
+	itemLog.Info("Ingested item", "duration", time.Since(start))
+}
+
+// pushToSinks fans item out to every sink configured for rssEndpoint. A
+// failure in one sink doesn't stop the others from running, and it
+// reports whether at least one sink succeeded. Once any sink succeeds
+// the item is recorded as ingested (see processItem), so a sink that
+// failed in that same pass is not retried on a later poll: there's no
+// per-sink delivery state, only the item-level hash. A feed with
+// multiple sinks that cares about every sink independently getting
+// every item should treat a failing sink as something to alert on, not
+// something this pipeline will resend.
+func (p *Pipeline) pushToSinks(ctx context.Context, itemLog *slog.Logger, rssEndpoint *config.RSSEndpoint, item sink.Item) bool {
+	sinks := p.sinksFor(rssEndpoint)
+	if len(sinks) == 0 {
+		itemLog.Warn("No sinks configured for feed, dropping item", "file", item.FileName)
+		return false
+	}
+
+	succeeded := false
+	failed := 0
+	for _, s := range sinks {
+		pushStart := time.Now()
+		if err := s.Push(ctx, item); err != nil {
+			itemLog.Error("Error pushing to sink", "file", item.FileName, "err", err, "duration", time.Since(pushStart))
+			failed++
+			continue
+		}
+		succeeded = true
+	}
+
+	if succeeded {
+		itemLog.Info("Successfully pushed item", "file", item.FileName)
+		if failed > 0 {
+			itemLog.Warn("Item will not be retried on the failed sink(s): it's already recorded as ingested", "file", item.FileName, "failed_sinks", failed)
+		}
+	}
+
+	return succeeded
+}
@@ -0,0 +1,74 @@
+package ingest
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClaimContentHashOnlyOneWinnerConcurrently(t *testing.T) {
+	p := &Pipeline{}
+
+	const goroutines = 50
+	var claimed int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	var attempted sync.WaitGroup
+	attempted.Add(goroutines)
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			release, ok := p.claimContentHash("same-hash")
+			if ok {
+				atomic.AddInt32(&claimed, 1)
+			}
+			// Hold any winning claim until every goroutine has had a
+			// chance to attempt it, so a winner releasing early can't
+			// let a later goroutine also "win".
+			attempted.Done()
+			attempted.Wait()
+			if ok {
+				release()
+			}
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	if claimed != 1 {
+		t.Errorf("claimContentHash: %d of %d concurrent callers won the claim, want exactly 1", claimed, goroutines)
+	}
+}
+
+func TestClaimContentHashReleaseAllowsReclaim(t *testing.T) {
+	p := &Pipeline{}
+
+	release, ok := p.claimContentHash("h")
+	if !ok {
+		t.Fatal("claimContentHash() first call should succeed")
+	}
+	if _, ok := p.claimContentHash("h"); ok {
+		t.Fatal("claimContentHash() should fail while the first claim is held")
+	}
+
+	release()
+
+	if _, ok := p.claimContentHash("h"); !ok {
+		t.Error("claimContentHash() should succeed again after release")
+	}
+}
+
+func TestClaimContentHashEmptyIsNeverContended(t *testing.T) {
+	p := &Pipeline{}
+
+	if _, ok := p.claimContentHash(""); !ok {
+		t.Error("claimContentHash(\"\") should always succeed")
+	}
+	if _, ok := p.claimContentHash(""); !ok {
+		t.Error("claimContentHash(\"\") should always succeed, even repeatedly")
+	}
+}
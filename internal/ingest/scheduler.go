@@ -0,0 +1,107 @@
+package ingest
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/litui/rss-rag-ingest/internal/config"
+)
+
+// jitterFraction is the maximum fraction of a feed's poll interval that
+// gets added or subtracted as random jitter, so that feeds configured
+// with the same interval don't all poll in lockstep.
+const jitterFraction = 0.2
+
+// Scheduler polls every configured feed on its own timer, independent of
+// the others, for as long as it runs.
+type Scheduler struct {
+	pipeline  *Pipeline
+	endpoints []*config.RSSEndpoint
+}
+
+// NewScheduler builds a Scheduler that polls endpoints using pipeline.
+func NewScheduler(pipeline *Pipeline, endpoints []*config.RSSEndpoint) *Scheduler {
+	return &Scheduler{pipeline: pipeline, endpoints: endpoints}
+}
+
+// Run blocks, polling every feed on its own schedule until ctx is
+// canceled.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, rssEndpoint := range s.endpoints {
+		wg.Add(1)
+		go func(rssEndpoint *config.RSSEndpoint) {
+			defer wg.Done()
+			s.runFeed(ctx, rssEndpoint)
+		}(rssEndpoint)
+	}
+
+	wg.Wait()
+}
+
+func (s *Scheduler) runFeed(ctx context.Context, rssEndpoint *config.RSSEndpoint) {
+	// sem bounds how many polls of this feed may be in flight at once, so
+	// a slow poll can't pile up behind a busy schedule.
+	sem := make(chan struct{}, rssEndpoint.Concurrency)
+	// inFlight tracks poll goroutines still running when ctx is
+	// canceled, so runFeed (and in turn Run) don't return until any
+	// in-progress poll has finished committing what it can.
+	var inFlight sync.WaitGroup
+	defer inFlight.Wait()
+
+	var schedule cron.Schedule
+	if rssEndpoint.Cron != "" {
+		parsed, err := cron.ParseStandard(rssEndpoint.Cron)
+		if err != nil {
+			slog.Error("Error parsing cron expression", "cron", rssEndpoint.Cron, "feed", rssEndpoint.Id, "err", err)
+			return
+		}
+		schedule = parsed
+	}
+
+	for {
+		var wait time.Duration
+		if schedule != nil {
+			wait = time.Until(schedule.Next(time.Now()))
+		} else {
+			wait = withJitter(rssEndpoint.PollInterval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		select {
+		case sem <- struct{}{}:
+			inFlight.Add(1)
+			go func() {
+				defer inFlight.Done()
+				defer func() { <-sem }()
+				if err := s.pipeline.ProcessEndpoint(ctx, rssEndpoint); err != nil {
+					slog.Error("Error polling feed", "feed", rssEndpoint.Id, "err", err)
+				}
+			}()
+		default:
+			slog.Warn("Skipping poll of feed: previous poll(s) still in flight", "feed", rssEndpoint.Id)
+		}
+	}
+}
+
+// withJitter returns d plus or minus a random fraction of itself, so
+// feeds sharing a poll interval don't all fire at once.
+func withJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	spread := float64(d) * jitterFraction
+	offset := (rand.Float64()*2 - 1) * spread
+	return d + time.Duration(offset)
+}
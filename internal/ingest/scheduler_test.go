@@ -0,0 +1,28 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithJitterStaysWithinFraction(t *testing.T) {
+	d := 10 * time.Minute
+	spread := time.Duration(float64(d) * jitterFraction)
+	min, max := d-spread, d+spread
+
+	for i := 0; i < 100; i++ {
+		got := withJitter(d)
+		if got < min || got > max {
+			t.Fatalf("withJitter(%v) = %v, want within [%v, %v]", d, got, min, max)
+		}
+	}
+}
+
+func TestWithJitterNonPositiveIsUnchanged(t *testing.T) {
+	if got := withJitter(0); got != 0 {
+		t.Errorf("withJitter(0) = %v, want 0", got)
+	}
+	if got := withJitter(-time.Second); got != -time.Second {
+		t.Errorf("withJitter(-1s) = %v, want -1s", got)
+	}
+}
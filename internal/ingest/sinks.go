@@ -0,0 +1,39 @@
+package ingest
+
+import (
+	"log/slog"
+
+	"golang.org/x/time/rate"
+
+	"github.com/litui/rss-rag-ingest/internal/config"
+	"github.com/litui/rss-rag-ingest/internal/sink"
+)
+
+// buildSinks resolves rssEndpoint's configured sinks, falling back to a
+// single owui sink for configs predating fanout support. owuiLimiter is
+// shared by every owui sink built here, since the upload rate limit is
+// per Open-WebUI instance, not per feed.
+func buildSinks(owuiCfg *config.OpenWebUI, owuiLimiter *rate.Limiter, rssEndpoint *config.RSSEndpoint) []sink.Sink {
+	if len(rssEndpoint.Sinks) == 0 {
+		if rssEndpoint.KnowledgeBaseId == "" {
+			return nil
+		}
+		return []sink.Sink{sink.NewOWUISink(owuiCfg, rssEndpoint.KnowledgeBaseId, owuiLimiter)}
+	}
+
+	sinks := make([]sink.Sink, 0, len(rssEndpoint.Sinks))
+	for _, sc := range rssEndpoint.Sinks {
+		switch sc.Type {
+		case "owui":
+			sinks = append(sinks, sink.NewOWUISink(owuiCfg, sc.KnowledgeBaseId, owuiLimiter))
+		case "micropub":
+			sinks = append(sinks, sink.NewMicropubSink(sc.MicropubEndpoint, sc.MicropubToken))
+		case "filesystem":
+			sinks = append(sinks, sink.NewFilesystemSink())
+		default:
+			slog.Warn("Unknown sink type, skipping", "type", sc.Type, "feed", rssEndpoint.Id)
+		}
+	}
+
+	return sinks
+}
@@ -0,0 +1,186 @@
+// Package owui uploads knowledge files to an Open-WebUI instance and
+// links them into a knowledge base.
+package owui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+
+	"github.com/litui/rss-rag-ingest/internal/config"
+	"github.com/litui/rss-rag-ingest/internal/progress"
+)
+
+// DefaultUploadRateLimit is the upload rate, in requests per second,
+// used when an OpenWebUI config doesn't set one.
+const DefaultUploadRateLimit = 2.0
+
+// NewLimiter builds the shared token-bucket limiter that throttles
+// knowledge uploads toward cfg's Open-WebUI instance.
+func NewLimiter(cfg *config.OpenWebUI) *rate.Limiter {
+	rps := DefaultUploadRateLimit
+	if cfg != nil && cfg.UploadRateLimit > 0 {
+		rps = cfg.UploadRateLimit
+	}
+	return rate.NewLimiter(rate.Limit(rps), 1)
+}
+
+// KnowledgeAddBody is the JSON payload sent to link an uploaded file into
+// a knowledge base.
+type KnowledgeAddBody struct {
+	FileId string `json:"file_id"`
+}
+
+// SendKnowledge streams content (size bytes, or -1 if unknown) to
+// Open-WebUI as fileName and attaches the resulting file to
+// knowledgeBaseId. reporter is optional; pass progress.Noop{} to ignore
+// progress entirely. ctx governs both the upload and the knowledge-base
+// link request, so a canceled ctx aborts an in-flight upload cleanly.
+func SendKnowledge(ctx context.Context, cfg *config.OpenWebUI, knowledgeBaseId string, fileName string, content io.Reader, size int64, reporter progress.Reporter) error {
+	if reporter == nil {
+		reporter = progress.Noop{}
+	}
+
+	fileUploadEndpoint := fmt.Sprintf("%s/v1/files/", cfg.APIEndpoint)
+	knowledgeLinkEndpoint := fmt.Sprintf("%s/v1/knowledge/%s/file/add", cfg.APIEndpoint, knowledgeBaseId)
+
+	hc := http.Client{}
+
+	// PART 1: File upload, streamed through a pipe so the whole
+	// multipart body never has to sit in memory at once.
+
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+
+	pr, pw := io.Pipe()
+	mpW := multipart.NewWriter(pw)
+	if err := mpW.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	reporter.Start(size)
+	go func() {
+		fw, err := mpW.CreateFormFile("file", fileName)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(fw, &countingReader{r: content, reporter: reporter}); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if err := mpW.Close(); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.Close()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fileUploadEndpoint, pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		reporter.Finish()
+		return err
+	}
+	// Must load Content-Type from multipart form to include boundary
+	req.Header.Add("Content-Type", mpW.FormDataContentType())
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", cfg.APIToken))
+	if size >= 0 {
+		req.ContentLength = multipartLength(boundary, fileName, size)
+	}
+
+	resp, err := hc.Do(req)
+	reporter.Finish()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return errors.New("non-200 response code from file upload")
+	}
+
+	// Parse the body so we can grab the file ID later
+	respBodyContents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	respBody := map[string]any{}
+	if err := yaml.Unmarshal(respBodyContents, &respBody); err != nil {
+		return err
+	}
+	fileId, ok := respBody["id"].(string)
+	if !ok {
+		return errors.New("file upload response missing id")
+	}
+
+	// PART 2: Link to knowledge base
+	knowledge := KnowledgeAddBody{
+		FileId: fileId,
+	}
+	knowledgeBytes, err := json.Marshal(knowledge)
+	if err != nil {
+		return err
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, knowledgeLinkEndpoint, bytes.NewReader(knowledgeBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", cfg.APIToken))
+
+	resp, err = hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return errors.New("non-200 response code")
+	}
+
+	return nil
+}
+
+// countingReader reports every byte read from r to reporter, so upload
+// progress reflects what's actually left the process rather than what
+// the caller handed in.
+type countingReader struct {
+	r        io.Reader
+	reporter progress.Reporter
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.reporter.Add(int64(n))
+	}
+	return n, err
+}
+
+// multipartLength computes the exact Content-Length of a single-file
+// "file" form upload of contentSize bytes, given a fixed boundary, so
+// callers that know their content's size can avoid chunked transfer
+// encoding.
+func multipartLength(boundary string, fileName string, contentSize int64) int64 {
+	var header bytes.Buffer
+	headerW := multipart.NewWriter(&header)
+	headerW.SetBoundary(boundary)
+	headerW.CreateFormFile("file", fileName)
+
+	var footer bytes.Buffer
+	footerW := multipart.NewWriter(&footer)
+	footerW.SetBoundary(boundary)
+	footerW.Close()
+
+	return int64(header.Len()) + contentSize + int64(footer.Len())
+}
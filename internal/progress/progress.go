@@ -0,0 +1,60 @@
+// Package progress defines a small callback interface for reporting
+// progress on long-running transfers, in the style of cheggaaa/pb.
+package progress
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Reporter receives progress callbacks for a single transfer. Start is
+// called once with the total size (or -1 if unknown), Add is called as
+// bytes move, and Finish is called exactly once when the transfer ends,
+// whether it succeeded or not.
+type Reporter interface {
+	Start(total int64)
+	Add(n int64)
+	Finish()
+}
+
+// Noop discards every callback. It's the default when no reporter is
+// supplied.
+type Noop struct{}
+
+func (Noop) Start(int64) {}
+func (Noop) Add(int64)   {}
+func (Noop) Finish()     {}
+
+// LogReporter logs a one-line throughput summary when the transfer
+// finishes, labeled with whatever identifies the transfer to a reader
+// (typically a file name).
+type LogReporter struct {
+	Label string
+
+	start       time.Time
+	total       int64
+	transferred int64
+}
+
+// NewLogReporter builds a LogReporter for label.
+func NewLogReporter(label string) *LogReporter {
+	return &LogReporter{Label: label}
+}
+
+func (r *LogReporter) Start(total int64) {
+	r.start = time.Now()
+	r.total = total
+}
+
+func (r *LogReporter) Add(n int64) {
+	r.transferred += n
+}
+
+func (r *LogReporter) Finish() {
+	elapsed := time.Since(r.start)
+	var throughputKBs float64
+	if elapsed > 0 {
+		throughputKBs = float64(r.transferred) / elapsed.Seconds() / 1024
+	}
+	slog.Info("Transfer finished", "label", r.Label, "bytes", r.transferred, "duration", elapsed.Round(time.Millisecond), "throughput_kbs", throughputKBs)
+}
@@ -0,0 +1,33 @@
+// Package render turns feed items into the markdown/text bodies that get
+// handed off to a sink.
+package render
+
+import (
+	"strings"
+
+	"github.com/jaytaylor/html2text"
+	"github.com/mmcdole/gofeed"
+)
+
+// HTMLToMarkdown converts an HTML document to plain markdown-ish text.
+func HTMLToMarkdown(html string) (string, error) {
+	return html2text.FromString(html, html2text.Options{
+		PrettyTables: true,
+		OmitLinks:    true,
+	})
+}
+
+// SkipLinkOnly reports whether a link-only (no data_in_link) item has
+// nothing worth ingesting.
+func SkipLinkOnly(item *gofeed.Item) bool {
+	if item.Description == "<p></p>" || item.Title == "" {
+		// For these purposes, we're only interested in filled-out text
+		// not images or lone links
+		return true
+	}
+	if strings.Contains(item.Title, "[No Title]") {
+		// This one's a quirk from _certain_ sites
+		return true
+	}
+	return false
+}
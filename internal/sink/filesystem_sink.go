@@ -0,0 +1,19 @@
+package sink
+
+import "context"
+
+// FilesystemSink is a no-op sink: the pipeline already caches every
+// item's content under content_dir before sinks run, so this sink exists
+// purely to let a feed be configured with "record it to disk and nowhere
+// else".
+type FilesystemSink struct{}
+
+// NewFilesystemSink builds a FilesystemSink.
+func NewFilesystemSink() *FilesystemSink {
+	return &FilesystemSink{}
+}
+
+// Push does nothing and always succeeds.
+func (s *FilesystemSink) Push(ctx context.Context, item Item) error {
+	return nil
+}
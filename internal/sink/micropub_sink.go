@@ -0,0 +1,85 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MicropubSink POSTs each item to a Micropub endpoint as a form-encoded
+// "h=entry", in the style of hugo-micropub. Items without a substantive
+// body (Item.HasBody false, as for data_in_link entries) become
+// bookmark-of entries; items with one become notes.
+type MicropubSink struct {
+	Endpoint string
+	Token    string
+	Client   *http.Client
+}
+
+// NewMicropubSink builds a Sink that posts entries to endpoint, using
+// token as a bearer credential.
+func NewMicropubSink(endpoint string, token string) *MicropubSink {
+	return &MicropubSink{
+		Endpoint: endpoint,
+		Token:    token,
+		Client:   &http.Client{},
+	}
+}
+
+// Push POSTs item to the Micropub endpoint.
+func (s *MicropubSink) Push(ctx context.Context, item Item) error {
+	form := url.Values{}
+	form.Set("h", "entry")
+	if item.Title != "" {
+		form.Set("name", item.Title)
+	}
+	if !item.Published.IsZero() {
+		form.Set("published", item.Published.Format(time.RFC3339))
+	}
+	for _, category := range item.Categories {
+		form.Add("category[]", category)
+	}
+
+	switch postKind(item) {
+	case KindBookmark:
+		form.Set("bookmark-of", item.Link)
+	default:
+		form.Set("content", string(item.Content))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.Token))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusCreated {
+		return errors.New("non-2xx response from micropub endpoint")
+	}
+
+	return nil
+}
+
+// postKind derives the Micropub post kind for item when it hasn't been
+// set explicitly: items with HasBody false become bookmarks,
+// everything else becomes a note.
+func postKind(item Item) Kind {
+	if item.Kind != KindAuto {
+		return item.Kind
+	}
+	if !item.HasBody {
+		return KindBookmark
+	}
+	return KindNote
+}
@@ -0,0 +1,46 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+
+	"golang.org/x/time/rate"
+
+	"github.com/litui/rss-rag-ingest/internal/config"
+	"github.com/litui/rss-rag-ingest/internal/owui"
+	"github.com/litui/rss-rag-ingest/internal/progress"
+)
+
+// OWUISink uploads an item's content as a file and attaches it to an
+// Open-WebUI knowledge base.
+type OWUISink struct {
+	Config          *config.OpenWebUI
+	KnowledgeBaseId string
+
+	// Limiter throttles uploads toward Open-WebUI. It's shared across
+	// every OWUISink built from the same Pipeline, since the rate limit
+	// is per Open-WebUI instance, not per feed.
+	Limiter *rate.Limiter
+}
+
+// NewOWUISink builds a Sink that pushes into knowledgeBaseId using cfg,
+// throttled by limiter.
+func NewOWUISink(cfg *config.OpenWebUI, knowledgeBaseId string, limiter *rate.Limiter) *OWUISink {
+	return &OWUISink{Config: cfg, KnowledgeBaseId: knowledgeBaseId, Limiter: limiter}
+}
+
+// Push uploads item.Content to Open-WebUI and links it into the
+// configured knowledge base, waiting on Limiter first so a burst of
+// items can't overrun Open-WebUI. A canceled ctx aborts the wait and
+// any in-flight upload.
+func (s *OWUISink) Push(ctx context.Context, item Item) error {
+	if s.Limiter != nil {
+		if err := s.Limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	reporter := progress.NewLogReporter(item.FileName)
+	reader := bytes.NewReader(item.Content)
+	return owui.SendKnowledge(ctx, s.Config, s.KnowledgeBaseId, item.FileName, reader, int64(len(item.Content)), reporter)
+}
@@ -0,0 +1,51 @@
+// Package sink defines the destinations a rendered feed item can be
+// pushed to, and ships a handful of ready-made implementations.
+package sink
+
+import (
+	"context"
+	"time"
+)
+
+// Kind classifies the kind of Micropub-style post an item represents.
+// It's honored by sinks that care (currently Micropub); others ignore it.
+type Kind string
+
+const (
+	// KindAuto lets the sink derive the post kind itself (the default).
+	KindAuto     Kind = ""
+	KindNote     Kind = "note"
+	KindBookmark Kind = "bookmark"
+)
+
+// Item is everything a Sink needs to record a single feed entry.
+type Item struct {
+	FileName    string
+	Content     []byte
+	ContentType string
+
+	Title      string
+	Link       string
+	Authors    []string
+	Categories []string
+	Published  time.Time
+
+	// HasBody reports whether Content holds substantive prose (as
+	// opposed to an item that's little more than a link).
+	HasBody bool
+	// Kind optionally overrides how a sink should classify this item.
+	Kind Kind
+}
+
+// Sink pushes a rendered Item to some destination. Implementations
+// should be safe to retry: a failed Push must not leave partial state
+// that causes a subsequent identical Push to behave differently.
+//
+// Note that the pipeline itself doesn't currently retry a sink that
+// failed while others for the same item succeeded: there's no per-sink
+// delivery state, only a single ingested/not-ingested flag per item, so
+// once any sink succeeds the item won't be offered to a failed sink
+// again on a later poll.
+type Sink interface {
+	Push(ctx context.Context, item Item) error
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const httpCacheTable = "http_cache"
+
+// CacheEntry is a cached HTTP response, keyed by URL, that can be
+// revalidated with a conditional GET.
+type CacheEntry struct {
+	ETag         string
+	LastModified string
+	ContentType  string
+	Body         []byte
+}
+
+func createHTTPCacheTable(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type='table' AND name=?;`, httpCacheTable)
+	if err != nil {
+		return fmt.Errorf("querying database: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		createStmt := fmt.Sprintf(`create table %s (
+			url text not null primary key,
+			etag text not null default '',
+			last_modified text not null default '',
+			content_type text not null default '',
+			body blob
+		);`, httpCacheTable)
+		if _, err := db.Exec(createStmt); err != nil {
+			return fmt.Errorf("creating table: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetCacheEntry returns the cached response for url, or nil if nothing
+// is cached yet.
+func GetCacheEntry(ctx context.Context, db *sql.DB, url string) (*CacheEntry, error) {
+	row := db.QueryRowContext(ctx, fmt.Sprintf("SELECT etag, last_modified, content_type, body FROM %s WHERE url=?;", httpCacheTable), url)
+
+	entry := &CacheEntry{}
+	if err := row.Scan(&entry.ETag, &entry.LastModified, &entry.ContentType, &entry.Body); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// PutCacheEntry upserts the cached response for url.
+func PutCacheEntry(ctx context.Context, db *sql.DB, url string, entry *CacheEntry) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`insert into %s (url, etag, last_modified, content_type, body) values (?, ?, ?, ?, ?)
+		on conflict(url) do update set etag=excluded.etag, last_modified=excluded.last_modified, content_type=excluded.content_type, body=excluded.body;`, httpCacheTable),
+		url, entry.ETag, entry.LastModified, entry.ContentType, entry.Body)
+	return err
+}
@@ -0,0 +1,185 @@
+// Package store persists which feed items have already been ingested.
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const rssRecordsTable = "rss_records"
+
+// ErrDuplicateContent is returned by RecordItem when its insert was
+// skipped because it conflicted with an existing row: typically the
+// content_hash unique index, when some other item already recorded the
+// same content hash. A conflict on the (rss_id, guid) primary key or on
+// hash is reported the same way.
+var ErrDuplicateContent = errors.New("content hash already recorded")
+
+// Open opens (and migrates) the sqlite database at path.
+func Open(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	if err := createRSSRecordsTable(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := createHTTPCacheTable(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func createRSSRecordsTable(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type='table' AND name=?;`, rssRecordsTable)
+	if err != nil {
+		return fmt.Errorf("querying database: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		// No table yet exists
+		createStmt := fmt.Sprintf("create table %s (rss_id text not null, guid text not null, hash text not null, content_hash text not null default '', PRIMARY KEY (rss_id, guid), UNIQUE(hash));", rssRecordsTable)
+		if _, err := db.Exec(createStmt); err != nil {
+			return fmt.Errorf("creating table: %w", err)
+		}
+		return ensureContentHashIndex(db)
+	}
+
+	if err := addContentHashColumn(db); err != nil {
+		return err
+	}
+
+	return ensureContentHashIndex(db)
+}
+
+// ensureContentHashIndex adds a unique index over content_hash, ignoring
+// the shared empty-string placeholder used when content dedup is
+// disabled, so two rows can never record the same non-empty content hash.
+func ensureContentHashIndex(db *sql.DB) error {
+	stmt := fmt.Sprintf("create unique index if not exists idx_%s_content_hash on %s(content_hash) where content_hash != '';", rssRecordsTable, rssRecordsTable)
+	if _, err := db.Exec(stmt); err != nil {
+		return fmt.Errorf("creating content_hash index: %w", err)
+	}
+	return nil
+}
+
+// addContentHashColumn migrates a pre-existing rss_records table (from
+// before content-based dedup) to add the content_hash column.
+func addContentHashColumn(db *sql.DB) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s);", rssRecordsTable))
+	if err != nil {
+		return fmt.Errorf("inspecting table: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid int
+		var name, colType string
+		var notNull, pk int
+		var dfltValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("scanning column info: %w", err)
+		}
+		if name == "content_hash" {
+			return nil
+		}
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("alter table %s add column content_hash text not null default '';", rssRecordsTable)); err != nil {
+		return fmt.Errorf("adding content_hash column: %w", err)
+	}
+
+	return nil
+}
+
+// GenItemHash derives the stable hash used to key an ingested item.
+func GenItemHash(rssId string, guid string) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s-%s", rssId, guid)))
+	return fmt.Sprintf("%x", hash)
+}
+
+// GenContentHash hashes an item's normalized extracted body text, so
+// the same article seen through two different feeds or GUIDs can be
+// recognized as a repost.
+func GenContentHash(normalizedText string) string {
+	hash := sha256.Sum256([]byte(normalizedText))
+	return fmt.Sprintf("%x", hash)
+}
+
+// FindItem returns the recorded hash for rssId/guid, or "" if it hasn't
+// been ingested yet.
+func FindItem(ctx context.Context, db *sql.DB, rssId string, guid string) string {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT hash FROM %s WHERE rss_id=? AND guid=?;", rssRecordsTable), rssId, guid)
+	if err != nil {
+		slog.Error("Error querying database", "err", err)
+		return ""
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return ""
+	}
+
+	var hash string
+	if err := rows.Scan(&hash); err != nil {
+		slog.Error("Error scanning row", "err", err)
+		return ""
+	}
+
+	return hash
+}
+
+// FindByContentHash reports whether some item with contentHash has
+// already been ingested, regardless of which feed/GUID it came through.
+// An empty contentHash never matches.
+func FindByContentHash(ctx context.Context, db *sql.DB, contentHash string) bool {
+	if contentHash == "" {
+		return false
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT 1 FROM %s WHERE content_hash=? LIMIT 1;", rssRecordsTable), contentHash)
+	if err != nil {
+		slog.Error("Error querying database", "err", err)
+		return false
+	}
+	defer rows.Close()
+
+	return rows.Next()
+}
+
+// RecordItem marks rssId/guid as ingested under hash, along with the
+// content hash of its body (may be empty if content dedup is disabled).
+// The content_hash unique index (see ensureContentHashIndex) makes this
+// the atomic backstop for content dedup: if some other insert already
+// claimed contentHash (or the same rssId/guid or hash), this insert is
+// silently skipped rather than erroring, and ErrDuplicateContent is
+// returned so the caller can treat it like any other already-ingested
+// item.
+func RecordItem(ctx context.Context, db *sql.DB, rssId string, guid string, hash string, contentHash string) error {
+	res, err := db.ExecContext(ctx, fmt.Sprintf("insert into %s (rss_id, guid, hash, content_hash) values (?, ?, ?, ?) on conflict do nothing;", rssRecordsTable), rssId, guid, hash, contentHash)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrDuplicateContent
+	}
+
+	return nil
+}
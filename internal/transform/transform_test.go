@@ -0,0 +1,118 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderDefaultTemplate(t *testing.T) {
+	p, err := NewPipeline(nil, "")
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+
+	published := time.Date(2026, time.March, 4, 12, 0, 0, 0, time.UTC)
+	out, err := p.Render(TemplateData{
+		Feed: FeedData{Title: "My Feed", Description: "About things"},
+		Item: ItemData{
+			Title:     "Hello",
+			Link:      "https://example.com/hello",
+			Authors:   []string{"Alice"},
+			Content:   "body text",
+			Published: published,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	for _, want := range []string{"# My Feed [About things]", "https://example.com/hello", "Alice", "body text"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Render() output missing %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderZeroPublishedOmitsDate(t *testing.T) {
+	p, err := NewPipeline(nil, "")
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+
+	out, err := p.Render(TemplateData{Item: ItemData{Content: "body"}})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	if strings.Contains(out, "0001") {
+		t.Errorf("Render() rendered zero-value Published as a date, got:\n%s", out)
+	}
+}
+
+func TestRenderCustomTemplate(t *testing.T) {
+	p, err := NewPipeline(nil, "{{.Item.Title}}: {{.Item.Content}}")
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+
+	out, err := p.Render(TemplateData{Item: ItemData{Title: "T", Content: "C"}})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	if out != "T: C" {
+		t.Errorf("Render() = %q, want %q", out, "T: C")
+	}
+}
+
+func TestRenderFrontMatterPrependsYAML(t *testing.T) {
+	p, err := NewPipeline([]string{StepFrontMatter}, "{{.Item.Content}}")
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+
+	out, err := p.Render(TemplateData{Item: ItemData{Title: "A Post", Content: "body"}})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	if !strings.HasPrefix(out, "---\n") {
+		t.Fatalf("Render() with front_matter should start with a YAML block, got:\n%s", out)
+	}
+	if !strings.Contains(out, "title: A Post") {
+		t.Errorf("Render() front matter missing title, got:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "body") {
+		t.Errorf("Render() should end with the template body, got:\n%s", out)
+	}
+}
+
+func TestRenderStripTags(t *testing.T) {
+	p, err := NewPipeline([]string{StepStripTags}, "{{.Item.Content}}")
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+
+	out, err := p.Render(TemplateData{Item: ItemData{Content: "<p>hi <b>there</b></p>"}})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	if out != "hi there" {
+		t.Errorf("Render() = %q, want %q", out, "hi there")
+	}
+}
+
+func TestRenderUnknownStepRejected(t *testing.T) {
+	if _, err := NewPipeline([]string{"bogus_step"}, ""); err == nil {
+		t.Error("NewPipeline() with an unknown step should return an error")
+	}
+}
+
+func TestStripTagsRemovesScriptAndStyleContents(t *testing.T) {
+	got := stripTags(`<style>.x{color:red}</style><p>hi</p><script>alert(1)</script>`)
+	if got != "hi" {
+		t.Errorf("stripTags() = %q, want %q", got, "hi")
+	}
+}